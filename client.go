@@ -1,6 +1,7 @@
 package webostv
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
@@ -10,10 +11,22 @@ import (
 	"log"
 	"net"
 	"net/url"
+	"sync"
+	"time"
 )
 
 const (
 	modelNameTag = "<modelName>LG TV</modelName>"
+
+	sendTimeout = 30 * time.Second
+
+	initialReconnectBackoff = 1 * time.Second
+	maxReconnectBackoff     = 30 * time.Second
+
+	// subscriptionQueueSize bounds how many undelivered responses a single
+	// subscription can buffer before dispatch starts dropping further
+	// updates for it.
+	subscriptionQueueSize = 16
 )
 
 var (
@@ -22,22 +35,106 @@ var (
 	errUnsupportedRegResponse    = errors.New("unsupported registration response was received")
 	errFailedToRegister          = errors.New("failed to register TV client")
 	errUnsupportedWsRspType      = errors.New("unsupported websocket response type")
+	errSendTimeout               = errors.New("timed out waiting for a response")
+	errClientClosed              = errors.New("client is disconnected")
 
 	//go:embed registration-payload.json
 	registrationPayload string
 )
 
-type callback func(rsp wsResponse)
+type callback struct {
+	fn         func(rsp wsResponse)
+	persistent bool
+
+	// queue and stop are only set for persistent callbacks. dispatch feeds
+	// queue rather than calling fn directly so that deliveries for a single
+	// subscription stay ordered and don't race each other. dispatch/finish
+	// never block on queue, dropping what doesn't fit; closing stop ends the
+	// delivery goroutine. See newPersistentCallback.
+	queue chan wsResponse
+	stop  chan struct{}
+
+	// msg is the original subscribe message, kept so run() can replay it
+	// after a reconnect.
+	msg Message
+}
+
+// newPersistentCallback wires fn to a queue drained by one dedicated
+// goroutine, so concurrent dispatches to the same subscription are
+// serialized and delivered in order instead of racing as independent
+// goroutines. The goroutine exits once stop is closed, which deleteCallback
+// and finish do when the subscription is torn down, after delivering
+// whatever was already queued (such as finish's sentinel).
+func newPersistentCallback(fn func(rsp wsResponse)) callback {
+	queue := make(chan wsResponse, subscriptionQueueSize)
+	stop := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case rsp := <-queue:
+				fn(rsp)
+			case <-stop:
+				for {
+					select {
+					case rsp := <-queue:
+						fn(rsp)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return callback{
+		fn:         fn,
+		persistent: true,
+		queue:      queue,
+		stop:       stop,
+	}
+}
 
 type Client interface {
-	Connect() error
+	Connect(ctx context.Context) error
 	Disconnect() error
-	SendBlocking(msg Message) (wsResponse, error)
+	SendBlocking(ctx context.Context, msg Message) (wsResponse, error)
+	Subscribe(msg Message) (<-chan wsResponse, func() error, error)
+	// Err returns the error that made the client give up, or nil while it
+	// is still connected or reconnecting. Only meaningful once Done is closed.
+	Err() error
+	// Done is closed once the client has permanently stopped, either
+	// because the context passed to Connect was cancelled or Disconnect
+	// was called.
+	Done() <-chan struct{}
+	// HasPermission reports whether the TV granted perm during
+	// registration, as declared in registration-payload.json's manifest.
+	HasPermission(perm string) bool
+	// Permissions returns every permission granted during registration.
+	Permissions() []string
+}
+
+// PermissionError is returned by a control method when the client wasn't
+// granted the permission it needs, instead of letting the opaque SSAP error
+// string reach the caller. This lets callers gate UI on capability and fail
+// fast without a round trip to the TV.
+type PermissionError struct {
+	URI      string
+	Required string
+	Granted  []string
 }
 
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("%s requires permission %q, granted: %v", e.URI, e.Required, e.Granted)
+}
+
+// ConfigStore persists the client key issued by a TV during pairing, keyed
+// by deviceID (the device's UDN, see DiscoveredDevice) so a single process
+// can manage credentials for more than one TV. See FileConfigStore for a
+// ready-to-use, file-backed implementation.
 type ConfigStore interface {
-	GetClientKey() (string, error)
-	SetClientKey(key string) error
+	GetClientKey(deviceID string) (string, error)
+	SetClientKey(deviceID string, key string) error
 }
 
 func New(config ConfigStore) (Client, error) {
@@ -45,22 +142,31 @@ func New(config ConfigStore) (Client, error) {
 }
 
 func NewWithKeyword(config ConfigStore, keyword string) (Client, error) {
-	discoveredURLs, err := discover("urn:schemas-upnp-org:device:MediaRenderer:1", keyword)
+	devices, err := Discover(keyword)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(discoveredURLs) == 0 {
+	if len(devices) == 0 {
 		return nil, errNoDevicesDiscovered
 	}
 
-	if len(discoveredURLs) > 1 {
+	if len(devices) > 1 {
 		return nil, errMultipleDevicesDiscovered
 	}
 
+	return NewWithDevice(config, devices[0])
+}
+
+// NewWithDevice builds a Client for a specific device, as returned by
+// Discover. Use this together with Discover to let callers pick among
+// several discovered TVs instead of relying on NewWithKeyword's
+// single-match requirement.
+func NewWithDevice(config ConfigStore, device DiscoveredDevice) (Client, error) {
 	c := defaultClient{
 		config:    config,
-		deviceURL: discoveredURLs[0],
+		deviceURL: device.Location,
+		deviceID:  device.UDN,
 	}
 
 	return &c, nil
@@ -69,22 +175,58 @@ func NewWithKeyword(config ConfigStore, keyword string) (Client, error) {
 type defaultClient struct {
 	config    ConfigStore
 	deviceURL string
+	deviceID  string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	wsConnMu  sync.Mutex
 	wsConn    *websocket.Conn
 	writeChan chan []byte
-	readChan  chan wsResponse
-	done      chan struct{}
-	callbacks map[string]callback
+
+	callbacksMu sync.Mutex
+	callbacks   map[string]callback
+
+	errMu sync.Mutex
+	err   error
+
+	permissionsMu sync.Mutex
+	permissions   map[string]struct{}
 }
 
-func (c *defaultClient) Connect() error {
-	parsedURL, err := url.Parse(c.deviceURL)
+// Connect dials the TV, registers the client and, once connected, keeps the
+// connection alive for the lifetime of ctx: a dropped socket is retried with
+// exponential backoff, re-sending the registration message (and any stored
+// client key) until ctx is cancelled or Disconnect is called.
+func (c *defaultClient) Connect(ctx context.Context) error {
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	c.writeChan = make(chan []byte, 1)
+	c.callbacks = make(map[string]callback)
+
+	wsConn, err := c.dialAndRegister()
 	if err != nil {
+		c.cancel()
 		return err
 	}
 
+	c.wsConn = wsConn
+
+	go c.run()
+
+	return nil
+}
+
+func (c *defaultClient) dialAndRegister() (*websocket.Conn, error) {
+	parsedURL, err := url.Parse(c.deviceURL)
+	if err != nil {
+		return nil, err
+	}
+
 	host, _, err := net.SplitHostPort(parsedURL.Host)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	wsURL := url.URL{
@@ -92,37 +234,44 @@ func (c *defaultClient) Connect() error {
 		Host:   host + ":3000",
 	}
 
-	wsConn, _, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
+	wsConn, _, err := websocket.DefaultDialer.DialContext(c.ctx, wsURL.String(), nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	message, err := createRegistrationMessage(c.config)
+	message, err := createRegistrationMessage(c.config, c.deviceID)
 	if err != nil {
-		return err
+		_ = wsConn.Close()
+		return nil, err
 	}
 
+	permissions := parsePermissions(message)
+
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
-		return err
+		_ = wsConn.Close()
+		return nil, err
 	}
 
 	err = wsConn.WriteMessage(websocket.TextMessage, messageBytes)
 	if err != nil {
-		return err
+		_ = wsConn.Close()
+		return nil, err
 	}
 
 	proceed := true
 	for proceed {
-		_, rspMessage, err := wsConn.ReadMessage()
+		rspMessage, err := readMessageContext(c.ctx, wsConn)
 		if err != nil {
-			return err
+			_ = wsConn.Close()
+			return nil, err
 		}
 
 		var rsp wsResponse
 		err = json.Unmarshal(rspMessage, &rsp)
 		if err != nil {
-			return err
+			_ = wsConn.Close()
+			return nil, err
 		}
 
 		switch rsp.Type {
@@ -130,40 +279,38 @@ func (c *defaultClient) Connect() error {
 			if "PROMPT" == rsp.Payload["pairingType"].(string) {
 				log.Println("Please accept the connection on TV")
 			} else {
-				return fmt.Errorf("%w: %s", errUnsupportedRegResponse, string(rspMessage))
+				_ = wsConn.Close()
+				return nil, fmt.Errorf("%w: %s", errUnsupportedRegResponse, string(rspMessage))
 			}
 		case wsRspErrorType:
-			return fmt.Errorf("%w: %s", errFailedToRegister, rsp.Error)
+			_ = wsConn.Close()
+			return nil, fmt.Errorf("%w: %s", errFailedToRegister, rsp.Error)
 		case wsRspRegisteredType:
 			clientKey := rsp.Payload["client-key"].(string)
-			err := c.config.SetClientKey(clientKey)
+			err := c.config.SetClientKey(c.deviceID, clientKey)
 			if err != nil {
-				return err
+				_ = wsConn.Close()
+				return nil, err
 			}
+			c.setPermissions(permissions)
 			proceed = false
 		default:
-			return fmt.Errorf("%w: %s", errUnsupportedWsRspType, rsp.Type)
+			_ = wsConn.Close()
+			return nil, fmt.Errorf("%w: %s", errUnsupportedWsRspType, rsp.Type)
 		}
 	}
 
-	c.wsConn = wsConn
-	c.writeChan = make(chan []byte, 1)
-	c.callbacks = make(map[string]callback)
-
-	go c.writeLoop()
-	go c.readLoop()
-
-	return nil
+	return wsConn, nil
 }
 
-func createRegistrationMessage(config ConfigStore) (map[string]interface{}, error) {
+func createRegistrationMessage(config ConfigStore, deviceID string) (map[string]interface{}, error) {
 	var payload map[string]interface{}
 	err := json.Unmarshal([]byte(registrationPayload), &payload)
 	if err != nil {
 		return nil, err
 	}
 
-	key, err := config.GetClientKey()
+	key, err := config.GetClientKey(deviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -181,53 +328,273 @@ func createRegistrationMessage(config ConfigStore) (map[string]interface{}, erro
 	return message, nil
 }
 
-func (c *defaultClient) writeLoop() {
+// parsePermissions reads the permissions declared under the registration
+// message's manifest, mirroring the scopes registration-payload.json asks
+// the TV to grant.
+func parsePermissions(message map[string]interface{}) []string {
+	payload, ok := message["payload"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	manifest, ok := payload["manifest"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	rawPermissions, ok := manifest["permissions"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	permissions := make([]string, 0, len(rawPermissions))
+	for _, p := range rawPermissions {
+		if perm, ok := p.(string); ok {
+			permissions = append(permissions, perm)
+		}
+	}
+
+	return permissions
+}
+
+func (c *defaultClient) setPermissions(permissions []string) {
+	granted := make(map[string]struct{}, len(permissions))
+	for _, p := range permissions {
+		granted[p] = struct{}{}
+	}
+
+	c.permissionsMu.Lock()
+	c.permissions = granted
+	c.permissionsMu.Unlock()
+}
+
+func (c *defaultClient) HasPermission(perm string) bool {
+	c.permissionsMu.Lock()
+	defer c.permissionsMu.Unlock()
+
+	_, granted := c.permissions[perm]
+	return granted
+}
+
+func (c *defaultClient) Permissions() []string {
+	c.permissionsMu.Lock()
+	defer c.permissionsMu.Unlock()
+
+	granted := make([]string, 0, len(c.permissions))
+	for p := range c.permissions {
+		granted = append(granted, p)
+	}
+
+	return granted
+}
+
+// run drives the connection established by Connect until ctx is cancelled,
+// reconnecting with exponential backoff whenever the socket fails.
+func (c *defaultClient) run() {
+	backoff := initialReconnectBackoff
+
+	for {
+		connErr := c.runConnection()
+
+		if c.ctx.Err() != nil {
+			c.finish(c.ctx.Err())
+			return
+		}
+
+		log.Println("webos-tv: connection lost, reconnecting: " + connErr.Error())
+
+		select {
+		case <-c.ctx.Done():
+			c.finish(c.ctx.Err())
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+
+		wsConn, err := c.dialAndRegister()
+		if err != nil {
+			if c.ctx.Err() != nil {
+				c.finish(c.ctx.Err())
+				return
+			}
+
+			log.Println("webos-tv: reconnect attempt failed: " + err.Error())
+			continue
+		}
+
+		c.wsConnMu.Lock()
+		c.wsConn = wsConn
+		c.wsConnMu.Unlock()
+
+		c.resubscribeAll(wsConn)
+
+		backoff = initialReconnectBackoff
+	}
+}
+
+// resubscribeAll re-sends every active subscription's original subscribe
+// message over wsConn. Without this, a subscription silently goes dead after
+// any reconnect: the TV has no memory of a subscriber it never heard from
+// again, and nothing else re-establishes it.
+func (c *defaultClient) resubscribeAll(wsConn *websocket.Conn) {
+	c.callbacksMu.Lock()
+	msgs := make([]Message, 0, len(c.callbacks))
+	for _, cb := range c.callbacks {
+		if cb.persistent {
+			msgs = append(msgs, cb.msg)
+		}
+	}
+	c.callbacksMu.Unlock()
+
+	for _, msg := range msgs {
+		msgJson, err := json.Marshal(msg)
+		if err != nil {
+			log.Println("webos-tv: failed to encode resubscribe message: " + err.Error())
+			continue
+		}
+
+		if err := wsConn.WriteMessage(websocket.TextMessage, msgJson); err != nil {
+			log.Println("webos-tv: failed to resend subscription after reconnect: " + err.Error())
+		}
+	}
+}
+
+// runConnection owns the current websocket connection until it fails or ctx
+// is cancelled, dispatching reads to matching callbacks and forwarding
+// queued writes.
+func (c *defaultClient) runConnection() error {
+	c.wsConnMu.Lock()
+	wsConn := c.wsConn
+	c.wsConnMu.Unlock()
+
+	readChan := make(chan wsResponse)
+	errChan := make(chan error, 1)
+
+	go forwardMessages(wsConn, readChan, errChan)
+
 	for {
 		select {
+		case msg := <-readChan:
+			c.dispatch(msg)
+		case err := <-errChan:
+			return err
 		case msg := <-c.writeChan:
-			err := c.wsConn.WriteMessage(websocket.TextMessage, msg)
-			if err != nil {
+			if err := wsConn.WriteMessage(websocket.TextMessage, msg); err != nil {
 				log.Println("failed to send message: " + err.Error())
 			}
-		case <-c.done:
-			return
+		case <-c.ctx.Done():
+			_ = wsConn.Close()
+			return c.ctx.Err()
 		}
 	}
 }
 
-func (c *defaultClient) readLoop() {
-	go func() {
-		forwardMessages(c.wsConn, c.readChan)
-	}()
+func (c *defaultClient) dispatch(msg wsResponse) {
+	c.callbacksMu.Lock()
+	cb, exists := c.callbacks[msg.ID]
+	if exists && !cb.persistent {
+		delete(c.callbacks, msg.ID)
+	}
+	c.callbacksMu.Unlock()
 
-	for {
+	if !exists {
+		return
+	}
+
+	if cb.persistent {
+		// Feed the per-subscription queue instead of spawning a goroutine
+		// per message: that keeps deliveries to a single subscription in
+		// order and bounds how many goroutines a busy subscription piles
+		// up. The send must never block: this runs on runConnection's one
+		// shared select loop, so a subscriber that falls behind would
+		// otherwise freeze reads, writes and Disconnect for every other
+		// caller too. A subscriber that can't keep up with subscriptionQueueSize
+		// buffered updates loses the overflow instead.
 		select {
-		case msg := <-c.readChan:
-			cb, exists := c.callbacks[msg.ID]
-			if exists {
-				delete(c.callbacks, msg.ID)
-				go cb(msg)
+		case cb.queue <- msg:
+		default:
+			log.Printf("webos-tv: subscription %s is falling behind, dropping update", msg.ID)
+		}
+		return
+	}
+
+	go cb.fn(msg)
+}
+
+// finish records the client's terminal error and unblocks everyone still
+// waiting on a callback.
+func (c *defaultClient) finish(err error) {
+	c.errMu.Lock()
+	c.err = err
+	c.errMu.Unlock()
+
+	c.callbacksMu.Lock()
+	removed := c.callbacks
+	c.callbacks = make(map[string]callback)
+	c.callbacksMu.Unlock()
+
+	for id, cb := range removed {
+		sentinel := wsResponse{ID: id, Type: wsRspErrorType, Error: errClientClosed.Error()}
+
+		if cb.persistent {
+			select {
+			case cb.queue <- sentinel:
+			default:
 			}
-		case <-c.done:
-			return
+			close(cb.stop)
+			continue
 		}
+
+		go cb.fn(sentinel)
+	}
+
+	close(c.done)
+}
+
+// readMessageContext reads one message from wsConn, giving up and closing
+// the connection as soon as ctx is cancelled instead of blocking on the
+// read indefinitely. Used during the registration handshake in
+// dialAndRegister, which runs before runConnection's select loop is there
+// to race reads against ctx.
+func readMessageContext(ctx context.Context, wsConn *websocket.Conn) ([]byte, error) {
+	type result struct {
+		msg []byte
+		err error
+	}
+
+	resultChan := make(chan result, 1)
+	go func() {
+		_, msg, err := wsConn.ReadMessage()
+		resultChan <- result{msg: msg, err: err}
+	}()
+
+	select {
+	case res := <-resultChan:
+		return res.msg, res.err
+	case <-ctx.Done():
+		_ = wsConn.Close()
+		return nil, ctx.Err()
 	}
 }
 
-func forwardMessages(wsConn *websocket.Conn, outChan chan wsResponse) {
+func forwardMessages(wsConn *websocket.Conn, outChan chan<- wsResponse, errChan chan<- error) {
 	for {
 		var msg wsResponse
 
 		err := wsConn.ReadJSON(&msg)
 		if err != nil {
-			if errors.Is(err, &json.UnmarshalTypeError{}) {
+			var unmarshalErr *json.UnmarshalTypeError
+			if errors.As(err, &unmarshalErr) {
 				log.Println("failed to unmarshal message: " + err.Error())
 				continue
-			} else {
-				// todo: forward is stopped atm, but the rest is working as usual.
-				// how to stop ws or swallow error but the we need to react to websocket closing
-				panic("failed to read WebSocket: " + err.Error())
 			}
+
+			errChan <- err
+			return
 		}
 
 		outChan <- msg
@@ -235,33 +602,126 @@ func forwardMessages(wsConn *websocket.Conn, outChan chan wsResponse) {
 }
 
 func (c *defaultClient) Disconnect() error {
-	err := c.wsConn.Close()
-	if err != nil {
-		return err
-	}
+	c.cancel()
+	<-c.done
 
 	return nil
 }
 
-func (c *defaultClient) SendBlocking(msg Message) (wsResponse, error) {
+func (c *defaultClient) Err() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+
+	return c.err
+}
+
+func (c *defaultClient) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *defaultClient) SendBlocking(ctx context.Context, msg Message) (wsResponse, error) {
 	msgJson, err := json.Marshal(msg)
 	if err != nil {
 		return wsResponse{}, err
 	}
 
 	callbackChan := make(chan wsResponse, 1)
-	c.callbacks[msg.ID] = func(rsp wsResponse) {
-		callbackChan <- rsp
+	c.callbacksMu.Lock()
+	c.callbacks[msg.ID] = callback{
+		fn: func(rsp wsResponse) {
+			callbackChan <- rsp
+		},
+	}
+	c.callbacksMu.Unlock()
+
+	select {
+	case c.writeChan <- msgJson:
+	case <-ctx.Done():
+		c.deleteCallback(msg.ID)
+		return wsResponse{}, ctx.Err()
+	case <-c.ctx.Done():
+		c.deleteCallback(msg.ID)
+		return wsResponse{}, c.ctx.Err()
 	}
 
-	c.writeChan <- msgJson
+	select {
+	case rsp := <-callbackChan:
+		if rsp.Type == wsRspErrorType {
+			err = fmt.Errorf("%s", rsp.Error)
+		}
+		return rsp, err
+	case <-ctx.Done():
+		c.deleteCallback(msg.ID)
+		return wsResponse{}, ctx.Err()
+	case <-c.ctx.Done():
+		c.deleteCallback(msg.ID)
+		return wsResponse{}, c.ctx.Err()
+	case <-time.After(sendTimeout):
+		c.deleteCallback(msg.ID)
+		return wsResponse{}, errSendTimeout
+	}
+}
+
+func (c *defaultClient) deleteCallback(id string) {
+	c.callbacksMu.Lock()
+	cb, exists := c.callbacks[id]
+	delete(c.callbacks, id)
+	c.callbacksMu.Unlock()
+
+	if exists && cb.persistent {
+		close(cb.stop)
+	}
+}
+
+// Subscribe registers msg as a persistent subscription and streams every
+// response sharing its ID into the returned channel. The returned cancel
+// func unsubscribes and stops the stream; it does not close the channel.
+func (c *defaultClient) Subscribe(msg Message) (<-chan wsResponse, func() error, error) {
+	msg.Type = SubscribeMsgType
+
+	msgJson, err := json.Marshal(msg)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	rsp := <-callbackChan
-	close(callbackChan)
+	rspChan := make(chan wsResponse, 1)
+	cb := newPersistentCallback(func(rsp wsResponse) {
+		rspChan <- rsp
+	})
+	cb.msg = msg
+
+	c.callbacksMu.Lock()
+	c.callbacks[msg.ID] = cb
+	c.callbacksMu.Unlock()
+
+	select {
+	case c.writeChan <- msgJson:
+	case <-c.ctx.Done():
+		c.deleteCallback(msg.ID)
+		return nil, nil, c.ctx.Err()
+	}
+
+	cancel := func() error {
+		c.deleteCallback(msg.ID)
+
+		unsubMsg := Message{
+			Type: UnsubscribeMsgType,
+			ID:   msg.ID,
+			URI:  msg.URI,
+		}
+
+		unsubMsgJson, err := json.Marshal(unsubMsg)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case c.writeChan <- unsubMsgJson:
+		case <-c.ctx.Done():
+		}
 
-	if rsp.Type == wsRspErrorType {
-		err = fmt.Errorf("%s", rsp.Error)
+		return nil
 	}
 
-	return rsp, err
+	return rspChan, cancel, nil
 }
@@ -0,0 +1,246 @@
+package webostv
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestDispatchPersistentDeliversInOrder dispatches exactly enough messages
+// to fit the subscription's queue without the consumer draining it, then
+// drains afterwards. That stays within subscriptionQueueSize so nothing
+// overflows, which isolates the property under test: deliveries come out in
+// the order they were dispatched.
+func TestDispatchPersistentDeliversInOrder(t *testing.T) {
+	c := &defaultClient{callbacks: make(map[string]callback)}
+
+	rspChan := make(chan wsResponse, 1)
+	cb := newPersistentCallback(func(rsp wsResponse) {
+		rspChan <- rsp
+	})
+
+	c.callbacksMu.Lock()
+	c.callbacks["sub-1"] = cb
+	c.callbacksMu.Unlock()
+
+	const n = subscriptionQueueSize
+	for i := 0; i < n; i++ {
+		c.dispatch(wsResponse{ID: "sub-1", Payload: map[string]interface{}{"seq": float64(i)}})
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case rsp := <-rspChan:
+			if got := rsp.Payload["seq"].(float64); got != float64(i) {
+				t.Fatalf("delivery %d arrived out of order: got seq %v", i, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for delivery %d", i)
+		}
+	}
+
+	c.deleteCallback("sub-1")
+}
+
+// TestDispatchPersistentDropsOnOverflowWithoutBlocking floods a subscription
+// well past subscriptionQueueSize without ever draining it. dispatch must
+// never block on a full subscription queue: blocking here would freeze
+// runConnection's shared select loop (client.go) for every other
+// subscription, SendBlocking call and Disconnect, not just this one slow
+// consumer.
+func TestDispatchPersistentDropsOnOverflowWithoutBlocking(t *testing.T) {
+	c := &defaultClient{callbacks: make(map[string]callback)}
+
+	rspChan := make(chan wsResponse, 1)
+	cb := newPersistentCallback(func(rsp wsResponse) {
+		rspChan <- rsp
+	})
+
+	c.callbacksMu.Lock()
+	c.callbacks["sub-1"] = cb
+	c.callbacksMu.Unlock()
+
+	const n = subscriptionQueueSize * 4
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			c.dispatch(wsResponse{ID: "sub-1", Payload: map[string]interface{}{"seq": float64(i)}})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch blocked on a full subscription queue instead of dropping the overflow")
+	}
+
+	last := -1
+	for {
+		select {
+		case rsp := <-rspChan:
+			seq := int(rsp.Payload["seq"].(float64))
+			if seq <= last {
+				t.Fatalf("deliveries out of order: got seq %d after %d", seq, last)
+			}
+			last = seq
+		case <-time.After(100 * time.Millisecond):
+			c.deleteCallback("sub-1")
+			return
+		}
+	}
+}
+
+func TestFinishDrainsPersistentAndOneShotCallbacks(t *testing.T) {
+	c := &defaultClient{callbacks: make(map[string]callback), done: make(chan struct{})}
+
+	oneShotChan := make(chan wsResponse, 1)
+	c.callbacksMu.Lock()
+	c.callbacks["one-shot"] = callback{fn: func(rsp wsResponse) { oneShotChan <- rsp }}
+	c.callbacksMu.Unlock()
+
+	subChan := make(chan wsResponse, 1)
+	c.callbacksMu.Lock()
+	c.callbacks["sub"] = newPersistentCallback(func(rsp wsResponse) { subChan <- rsp })
+	c.callbacksMu.Unlock()
+
+	c.finish(errClientClosed)
+
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("finish did not close done")
+	}
+
+	select {
+	case rsp := <-oneShotChan:
+		if rsp.Error != errClientClosed.Error() {
+			t.Fatalf("unexpected sentinel error: %s", rsp.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("one-shot callback never received the sentinel error")
+	}
+
+	select {
+	case rsp := <-subChan:
+		if rsp.Error != errClientClosed.Error() {
+			t.Fatalf("unexpected sentinel error: %s", rsp.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription callback never received the sentinel error")
+	}
+}
+
+// TestDialAndRegisterRespectsContextCancellation guards against the
+// reconnect loop getting stuck inside dialAndRegister: the TV registration
+// port is hard-coded to 3000, so the fake server binds there directly.
+func TestDialAndRegisterRespectsContextCancellation(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:3000")
+	if err != nil {
+		t.Skipf("cannot bind 127.0.0.1:3000 in this environment: %v", err)
+	}
+
+	upgrader := websocket.Upgrader{}
+	hangUntilClosed := make(chan struct{})
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Accept the registration write but never answer it, mimicking a
+		// TV that never completes the pairing handshake.
+		_, _, _ = conn.ReadMessage()
+		<-hangUntilClosed
+	}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+	defer close(hangUntilClosed)
+
+	c := &defaultClient{
+		deviceURL: "http://127.0.0.1:0",
+		config:    newFakeConfigStore(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx = ctx
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := c.dialAndRegister()
+		errChan <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Fatal("expected dialAndRegister to return an error once ctx was cancelled")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("dialAndRegister did not return after context cancellation; a reconnect attempt would hang Disconnect forever")
+	}
+}
+
+func TestSetPermissionsAndHasPermission(t *testing.T) {
+	c := &defaultClient{}
+	c.setPermissions([]string{"CONTROL_AUDIO", "READ_CURRENT_CHANNEL"})
+
+	if !c.HasPermission("CONTROL_AUDIO") {
+		t.Fatal("expected CONTROL_AUDIO to be granted")
+	}
+
+	if c.HasPermission("WRITE_NOTIFICATION_TOAST") {
+		t.Fatal("expected WRITE_NOTIFICATION_TOAST to not be granted")
+	}
+
+	granted := c.Permissions()
+	sort.Strings(granted)
+	want := []string{"CONTROL_AUDIO", "READ_CURRENT_CHANNEL"}
+	if !reflect.DeepEqual(granted, want) {
+		t.Fatalf("Permissions() = %v, want %v", granted, want)
+	}
+}
+
+func TestPermissionErrorMessage(t *testing.T) {
+	err := &PermissionError{URI: "ssap://audio/volumeUp", Required: "CONTROL_AUDIO", Granted: []string{"READ_CURRENT_CHANNEL"}}
+
+	got := err.Error()
+	for _, want := range []string{"ssap://audio/volumeUp", "CONTROL_AUDIO", "READ_CURRENT_CHANNEL"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("Error() = %q, expected it to mention %q", got, want)
+		}
+	}
+}
+
+type fakeConfigStore struct {
+	keys map[string]string
+}
+
+func newFakeConfigStore() *fakeConfigStore {
+	return &fakeConfigStore{keys: make(map[string]string)}
+}
+
+func (s *fakeConfigStore) GetClientKey(deviceID string) (string, error) {
+	return s.keys[deviceID], nil
+}
+
+func (s *fakeConfigStore) SetClientKey(deviceID string, key string) error {
+	s.keys[deviceID] = key
+	return nil
+}
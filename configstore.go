@@ -0,0 +1,91 @@
+package webostv
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	configDirName  = "webos-tv"
+	configFileName = "keys.json"
+)
+
+// FileConfigStore is a ConfigStore backed by a JSON file under
+// $XDG_CONFIG_HOME/webos-tv/keys.json (see os.UserConfigDir for the exact
+// location on each platform), mapping device ID to client key so a single
+// process can pair with more than one TV.
+type FileConfigStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileConfigStore creates a FileConfigStore, creating its containing
+// directory if it doesn't already exist.
+func NewFileConfigStore() (*FileConfigStore, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(configDir, configDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileConfigStore{path: filepath.Join(dir, configFileName)}, nil
+}
+
+func (s *FileConfigStore) GetClientKey(deviceID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.readKeys()
+	if err != nil {
+		return "", err
+	}
+
+	return keys[deviceID], nil
+}
+
+func (s *FileConfigStore) SetClientKey(deviceID string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.readKeys()
+	if err != nil {
+		return err
+	}
+
+	keys[deviceID] = key
+
+	return s.writeKeys(keys)
+}
+
+func (s *FileConfigStore) readKeys() (map[string]string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]string), nil
+		}
+
+		return nil, err
+	}
+
+	keys := make(map[string]string)
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (s *FileConfigStore) writeKeys(keys map[string]string) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, data, 0o600)
+}
@@ -0,0 +1,39 @@
+package webostv
+
+import "testing"
+
+func TestFileConfigStoreRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	store, err := NewFileConfigStore()
+	if err != nil {
+		t.Fatalf("NewFileConfigStore: %v", err)
+	}
+
+	if key, err := store.GetClientKey("device-1"); err != nil || key != "" {
+		t.Fatalf("expected no key before pairing, got %q, err %v", key, err)
+	}
+
+	if err := store.SetClientKey("device-1", "secret-1"); err != nil {
+		t.Fatalf("SetClientKey(device-1): %v", err)
+	}
+
+	if err := store.SetClientKey("device-2", "secret-2"); err != nil {
+		t.Fatalf("SetClientKey(device-2): %v", err)
+	}
+
+	// A second store pointed at the same XDG_CONFIG_HOME must see both
+	// keys, proving they actually persisted to disk rather than just
+	// living in the first store's in-memory state.
+	reopened, err := NewFileConfigStore()
+	if err != nil {
+		t.Fatalf("NewFileConfigStore (reopen): %v", err)
+	}
+
+	for deviceID, want := range map[string]string{"device-1": "secret-1", "device-2": "secret-2"} {
+		got, err := reopened.GetClientKey(deviceID)
+		if err != nil || got != want {
+			t.Fatalf("GetClientKey(%q) = %q, %v, want %q, nil", deviceID, got, err, want)
+		}
+	}
+}
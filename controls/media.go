@@ -1,6 +1,7 @@
 package controls
 
 import (
+	"context"
 	webostv "github.com/extbe/webos-tv"
 	"github.com/google/uuid"
 )
@@ -10,6 +11,8 @@ const (
 	uriVolumeDown = "ssap://audio/volumeDown"
 	uriGetVolume  = "ssap://audio/getVolume"
 	uriSetVolume  = "ssap://audio/setVolume"
+
+	permissionControlAudio = "CONTROL_AUDIO"
 )
 
 type Media struct {
@@ -20,20 +23,28 @@ func NewMedia(c webostv.Client) Media {
 	return Media{c: c}
 }
 
-func (m Media) VolumeUp() error {
+func (m Media) VolumeUp(ctx context.Context) error {
+	if err := requirePermission(m.c, uriVolumeUp, permissionControlAudio); err != nil {
+		return err
+	}
+
 	msg := newRequestMessage()
 	msg.URI = uriVolumeUp
 
-	_, err := m.c.SendBlocking(msg)
+	_, err := m.c.SendBlocking(ctx, msg)
 
 	return err
 }
 
-func (m Media) VolumeDown() error {
+func (m Media) VolumeDown(ctx context.Context) error {
+	if err := requirePermission(m.c, uriVolumeDown, permissionControlAudio); err != nil {
+		return err
+	}
+
 	msg := newRequestMessage()
 	msg.URI = uriVolumeDown
 
-	_, err := m.c.SendBlocking(msg)
+	_, err := m.c.SendBlocking(ctx, msg)
 
 	return err
 }
@@ -51,14 +62,18 @@ func (m Media) VolumeDown() error {
 //	return rsp.Payload
 //}
 
-func (m Media) SetVolume(level int) error {
+func (m Media) SetVolume(ctx context.Context, level int) error {
+	if err := requirePermission(m.c, uriSetVolume, permissionControlAudio); err != nil {
+		return err
+	}
+
 	msg := newRequestMessage()
 	msg.URI = uriSetVolume
 	msg.Payload = map[string]interface{}{
 		"volume": level,
 	}
 
-	_, err := m.c.SendBlocking(msg)
+	_, err := m.c.SendBlocking(ctx, msg)
 
 	return err
 }
@@ -69,3 +84,14 @@ func newRequestMessage() webostv.Message {
 		ID:   uuid.New().String(),
 	}
 }
+
+// requirePermission fails fast with a *webostv.PermissionError when c
+// wasn't granted required, instead of sending uri and surfacing whatever
+// opaque error string the TV responds with.
+func requirePermission(c webostv.Client, uri string, required string) error {
+	if c.HasPermission(required) {
+		return nil
+	}
+
+	return &webostv.PermissionError{URI: uri, Required: required, Granted: c.Permissions()}
+}
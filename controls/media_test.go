@@ -0,0 +1,81 @@
+package controls
+
+import (
+	"context"
+	"testing"
+
+	webostv "github.com/extbe/webos-tv"
+)
+
+// fakeClient embeds a nil webostv.Client so it satisfies the interface
+// (including SendBlocking, whose response type is unexported and so can't
+// be named from this package) while only overriding the permission methods
+// these tests exercise. Calling any other method panics on the nil
+// embedded interface, which is exactly what we want: requirePermission
+// must reject before a control method ever reaches SendBlocking.
+type fakeClient struct {
+	webostv.Client
+	permissions map[string]struct{}
+}
+
+func newFakeClient(granted ...string) *fakeClient {
+	permissions := make(map[string]struct{}, len(granted))
+	for _, p := range granted {
+		permissions[p] = struct{}{}
+	}
+
+	return &fakeClient{permissions: permissions}
+}
+
+func (c *fakeClient) HasPermission(perm string) bool {
+	_, ok := c.permissions[perm]
+	return ok
+}
+
+func (c *fakeClient) Permissions() []string {
+	granted := make([]string, 0, len(c.permissions))
+	for p := range c.permissions {
+		granted = append(granted, p)
+	}
+
+	return granted
+}
+
+func TestRequirePermissionGranted(t *testing.T) {
+	c := newFakeClient(permissionControlAudio)
+
+	if err := requirePermission(c, uriVolumeUp, permissionControlAudio); err != nil {
+		t.Fatalf("expected no error when the permission is granted, got %v", err)
+	}
+}
+
+func TestRequirePermissionDenied(t *testing.T) {
+	c := newFakeClient()
+
+	err := requirePermission(c, uriVolumeUp, permissionControlAudio)
+	if err == nil {
+		t.Fatal("expected an error when the permission wasn't granted")
+	}
+
+	permErr, ok := err.(*webostv.PermissionError)
+	if !ok {
+		t.Fatalf("expected *webostv.PermissionError, got %T", err)
+	}
+
+	if permErr.URI != uriVolumeUp || permErr.Required != permissionControlAudio {
+		t.Fatalf("unexpected permission error: %+v", permErr)
+	}
+}
+
+func TestVolumeUpFailsFastWithoutPermission(t *testing.T) {
+	m := NewMedia(newFakeClient())
+
+	err := m.VolumeUp(context.Background())
+	if err == nil {
+		t.Fatal("expected VolumeUp to fail fast without CONTROL_AUDIO")
+	}
+
+	if _, ok := err.(*webostv.PermissionError); !ok {
+		t.Fatalf("expected *webostv.PermissionError, got %T", err)
+	}
+}
@@ -2,23 +2,31 @@ package webostv
 
 import (
 	"bytes"
+	"encoding/xml"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"text/template"
+	"time"
 )
 
 const (
-	multicastAddress = "239.255.255.250"
-	multicastPort    = 1900
-	mx               = 2
+	multicastAddressV4 = "239.255.255.250"
+	multicastAddressV6 = "ff02::c"
+	ssdpPort           = 1900
+
+	defaultMX = 2
 
 	timeoutSeconds = 5
 
+	mediaRendererService = "urn:schemas-upnp-org:device:MediaRenderer:1"
+
 	msgTemplate = "M-SEARCH * HTTP/1.1\r\n" +
-		"HOST: {{.multicastAddress}}:{{.multicastPort}}\r\n" +
+		"HOST: {{.host}}\r\n" +
 		"MAN: \"ssdp:discover\"\r\n" +
 		"ST: {{.st}}\r\n" +
 		"MX: {{.mx}}\r\n" +
@@ -27,113 +35,264 @@ const (
 	locationHeaderPrefix = "Location: "
 )
 
-var (
-	discoveryMsgTemplate  *template.Template
-	multicastAddressBytes [4]byte
-)
+// DiscoveredDevice describes an LG device found via SSDP, together with the
+// identifying fields parsed from its UPnP device description XML.
+type DiscoveredDevice struct {
+	Location     string
+	FriendlyName string
+	ModelName    string
+	UDN          string
+	Manufacturer string
+}
 
-func init() {
-	tpl, err := template.New("discovery-message").Parse(msgTemplate)
+type deviceDescription struct {
+	Device struct {
+		FriendlyName string `xml:"friendlyName"`
+		ModelName    string `xml:"modelName"`
+		UDN          string `xml:"UDN"`
+		Manufacturer string `xml:"manufacturer"`
+	} `xml:"device"`
+}
+
+// DiscoverOptions customizes a discovery run. The zero value searches every
+// up, multicast-capable interface for the UPnP MediaRenderer device type
+// over both IPv4 and IPv6.
+type DiscoverOptions struct {
+	// InterfaceName restricts the search to a single network interface
+	// (e.g. "eth0"). Empty searches every multicast-capable interface,
+	// which is what you want on hosts with more than one (common: a
+	// docker bridge picked instead of the LAN).
+	InterfaceName string
+	// MX is the SSDP MX (max wait, in seconds) advertised in the
+	// M-SEARCH request. Defaults to 2.
+	MX int
+	// ST is the SSDP search target. Defaults to the UPnP MediaRenderer
+	// device type.
+	ST string
+}
+
+func (opts DiscoverOptions) withDefaults() DiscoverOptions {
+	if opts.MX == 0 {
+		opts.MX = defaultMX
+	}
+
+	if opts.ST == "" {
+		opts.ST = mediaRendererService
+	}
+
+	return opts
+}
+
+// Discoverer runs SSDP discovery and returns the device description
+// locations that responded, before keyword filtering and XML parsing.
+// The default implementation is multicastDiscoverer; swap it via
+// DiscoverWith to plug in a different transport (e.g. a fake for tests).
+type Discoverer interface {
+	DiscoverLocations(opts DiscoverOptions) ([]string, error)
+}
+
+var defaultDiscoverer Discoverer = multicastDiscoverer{}
+
+// Discover runs SSDP discovery for LG media renderer devices whose device
+// description contains keyword, returning one DiscoveredDevice per match.
+// Unlike NewWithKeyword, it does not error out when more than one device is
+// found, letting callers implement their own selection (CLI pickers,
+// multi-TV automation, etc).
+func Discover(keyword string) ([]DiscoveredDevice, error) {
+	return discover(mediaRendererService, keyword)
+}
+
+// DiscoverWith runs discovery through d with opts, letting callers tune the
+// search (interface, MX, ST) or plug in a custom Discoverer.
+func DiscoverWith(d Discoverer, opts DiscoverOptions, keyword string) ([]DiscoveredDevice, error) {
+	locations, err := d.DiscoverLocations(opts.withDefaults())
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	discoveryMsgTemplate = tpl
+	devices := make(map[string]DiscoveredDevice)
+
+	for _, location := range locations {
+		if _, alreadyFound := devices[location]; alreadyFound {
+			continue
+		}
 
-	for i, octetStr := range strings.Split(multicastAddress, ".") {
-		octet, err := strconv.Atoi(octetStr)
+		device, matches, err := fetchDevice(location, keyword)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 
-		multicastAddressBytes[i] = byte(octet)
+		if matches {
+			devices[location] = device
+		}
 	}
+
+	return getDeviceList(devices), nil
 }
 
-func discover(service string, keyword string) ([]string, error) {
-	var msg bytes.Buffer
-	err := discoveryMsgTemplate.Execute(&msg, map[string]interface{}{
-		"multicastAddress": multicastAddress,
-		"multicastPort":    multicastPort,
-		"st":               service,
-		"mx":               mx,
-	})
+// discover runs the default multicast discovery for the given SSDP search
+// target. Kept as a thin wrapper around DiscoverWith/multicastDiscoverer.
+func discover(service string, keyword string) ([]DiscoveredDevice, error) {
+	return DiscoverWith(defaultDiscoverer, DiscoverOptions{ST: service}, keyword)
+}
+
+// multicastDiscoverer is the default Discoverer. It enumerates up,
+// multicast-capable interfaces via net.Interfaces(), sends an M-SEARCH on
+// each over both IPv4 and IPv6, and merges the responses.
+type multicastDiscoverer struct{}
+
+func (multicastDiscoverer) DiscoverLocations(opts DiscoverOptions) ([]string, error) {
+	ifaces, err := multicastInterfaces(opts.InterfaceName)
 	if err != nil {
 		return nil, err
 	}
 
-	fd, err := prepareDiscoverySocket()
-	if err != nil {
-		return nil, err
+	var jobs []searchJob
+	for _, multicastAddress := range []string{multicastAddressV4, multicastAddressV6} {
+		msg, err := buildSearchMessage(multicastAddress, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		groupAddr := &net.UDPAddr{IP: net.ParseIP(multicastAddress), Port: ssdpPort}
+		for _, iface := range ifaces {
+			jobs = append(jobs, searchJob{iface: iface, groupAddr: groupAddr, msg: msg})
+		}
 	}
 
-	dstAddr := &syscall.SockaddrInet4{
-		Port: multicastPort,
-		Addr: multicastAddressBytes,
+	return searchAll(jobs), nil
+}
+
+// searchJob is one (interface, address family) pair to M-SEARCH.
+type searchJob struct {
+	iface     net.Interface
+	groupAddr *net.UDPAddr
+	msg       []byte
+}
+
+// searchAll runs every job concurrently and merges the responses. Each job
+// still blocks for up to timeoutSeconds waiting for replies, but jobs wait
+// in parallel, so adding interfaces or address families doesn't multiply
+// discovery's wall-clock time the way running them sequentially would. A
+// job that fails (e.g. an interface that can't join the group) is skipped
+// rather than failing discovery as a whole.
+func searchAll(jobs []searchJob) []string {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		locations = make(map[string]struct{})
+	)
+
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job searchJob) {
+			defer wg.Done()
+
+			found, err := searchOnInterface(job.iface, job.groupAddr, job.msg)
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			for _, location := range found {
+				locations[location] = struct{}{}
+			}
+			mu.Unlock()
+		}(job)
 	}
-	err = syscall.Sendto(fd, msg.Bytes(), 0, dstAddr)
+
+	wg.Wait()
+
+	result := make([]string, 0, len(locations))
+	for location := range locations {
+		result = append(result, location)
+	}
+
+	return result
+}
+
+func multicastInterfaces(name string) ([]net.Interface, error) {
+	ifaces, err := net.Interfaces()
 	if err != nil {
 		return nil, err
 	}
 
-	locations := make(map[string]struct{})
-
-	for {
-		buf := make([]byte, 1024)
-		_, _, err := syscall.Recvfrom(fd, buf, 0)
-		if err != nil {
-			errno, ok := err.(syscall.Errno)
-			if ok && errno.Timeout() {
-				break
-			} else {
-				return nil, err
+	if name != "" {
+		for _, iface := range ifaces {
+			if iface.Name == name {
+				return []net.Interface{iface}, nil
 			}
 		}
 
-		location := parseLocation(buf)
+		return nil, fmt.Errorf("discovery interface %q not found", name)
+	}
 
-		isLocationValid, err := validateDevice(location, keyword)
-		if err != nil {
-			return nil, err
+	usable := make([]net.Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
 		}
 
-		if isLocationValid {
-			locations[location] = struct{}{}
-		}
+		usable = append(usable, iface)
 	}
 
-	return getMapKeys(locations), nil
+	return usable, nil
 }
 
-func prepareDiscoverySocket() (int, error) {
-	// ForkLock docs state that socket syscall requires the lock
-	syscall.ForkLock.Lock()
-
-	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+func searchOnInterface(iface net.Interface, groupAddr *net.UDPAddr, msg []byte) ([]string, error) {
+	conn, err := net.ListenMulticastUDP("udp", &iface, groupAddr)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	defer conn.Close()
 
-	syscall.ForkLock.Unlock()
+	if _, err := conn.WriteToUDP(msg, groupAddr); err != nil {
+		return nil, err
+	}
 
-	if err = syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
-		_ = syscall.Close(fd)
-		return 0, err
+	if err := conn.SetReadDeadline(time.Now().Add(timeoutSeconds * time.Second)); err != nil {
+		return nil, err
 	}
 
-	if err = syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, 2); err != nil {
-		_ = syscall.Close(fd)
-		return 0, err
+	var locations []string
+	buf := make([]byte, 1024)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+
+		if location := parseLocation(buf[:n]); location != "" {
+			locations = append(locations, location)
+		}
 	}
 
-	timeVal := new(syscall.Timeval)
-	timeVal.Sec = timeoutSeconds
-	if err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, timeVal); err != nil {
-		_ = syscall.Close(fd)
-		return 0, err
+	return locations, nil
+}
+
+func buildSearchMessage(multicastAddress string, opts DiscoverOptions) ([]byte, error) {
+	var msg bytes.Buffer
+	err := discoveryMsgTemplate.Execute(&msg, map[string]interface{}{
+		"host": net.JoinHostPort(multicastAddress, strconv.Itoa(ssdpPort)),
+		"st":   opts.ST,
+		"mx":   opts.MX,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return msg.Bytes(), nil
+}
+
+var discoveryMsgTemplate *template.Template
+
+func init() {
+	tpl, err := template.New("discovery-message").Parse(msgTemplate)
+	if err != nil {
+		panic(err)
 	}
 
-	return fd, nil
+	discoveryMsgTemplate = tpl
 }
 
 func parseLocation(buf []byte) string {
@@ -147,25 +306,42 @@ func parseLocation(buf []byte) string {
 	return ""
 }
 
-func validateDevice(location string, keyword string) (bool, error) {
+func fetchDevice(location string, keyword string) (DiscoveredDevice, bool, error) {
 	response, err := http.Get(location)
 	if err != nil {
-		return false, err
+		return DiscoveredDevice{}, false, err
 	}
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		return false, err
+		return DiscoveredDevice{}, false, err
+	}
+
+	if !strings.Contains(string(body), keyword) {
+		return DiscoveredDevice{}, false, nil
+	}
+
+	var description deviceDescription
+	if err := xml.Unmarshal(body, &description); err != nil {
+		return DiscoveredDevice{}, false, err
 	}
 
-	return strings.Contains(string(body), keyword), nil
+	device := DiscoveredDevice{
+		Location:     location,
+		FriendlyName: description.Device.FriendlyName,
+		ModelName:    description.Device.ModelName,
+		UDN:          description.Device.UDN,
+		Manufacturer: description.Device.Manufacturer,
+	}
+
+	return device, true, nil
 }
 
-func getMapKeys(locations map[string]struct{}) []string {
-	deviceLocations := make([]string, len(locations))
+func getDeviceList(devices map[string]DiscoveredDevice) []DiscoveredDevice {
+	deviceList := make([]DiscoveredDevice, len(devices))
 	i := 0
-	for location := range locations {
-		deviceLocations[i] = location
+	for _, device := range devices {
+		deviceList[i] = device
 		i++
 	}
-	return deviceLocations
+	return deviceList
 }
@@ -0,0 +1,107 @@
+package webostv
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+type fakeDiscoverer struct {
+	locations []string
+}
+
+func (f fakeDiscoverer) DiscoverLocations(opts DiscoverOptions) ([]string, error) {
+	return f.locations, nil
+}
+
+const deviceDescriptionTemplate = `<?xml version="1.0"?>
+<root><device>
+	<friendlyName>%s</friendlyName>
+	<modelName>OLED55</modelName>
+	<UDN>uuid:test-udn</UDN>
+	<manufacturer>LG Electronics</manufacturer>
+</device></root>`
+
+func newDeviceServer(t *testing.T, friendlyName string) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, deviceDescriptionTemplate, friendlyName)
+	}))
+}
+
+// TestDiscoverWithFakeDiscoverer exercises DiscoverWith through a fake
+// Discoverer, the plug point the Discoverer doc comment calls out for
+// tests, verifying that locations are deduped and filtered by keyword.
+func TestDiscoverWithFakeDiscoverer(t *testing.T) {
+	match := newDeviceServer(t, "Living Room LG TV")
+	defer match.Close()
+
+	noMatch := newDeviceServer(t, "Some Other Device")
+	defer noMatch.Close()
+
+	d := fakeDiscoverer{locations: []string{match.URL, match.URL, noMatch.URL}}
+
+	devices, err := DiscoverWith(d, DiscoverOptions{}, "LG TV")
+	if err != nil {
+		t.Fatalf("DiscoverWith returned an error: %v", err)
+	}
+
+	if len(devices) != 1 {
+		t.Fatalf("expected one matching, deduped device, got %d: %+v", len(devices), devices)
+	}
+
+	got := devices[0]
+	if got.Location != match.URL || got.FriendlyName != "Living Room LG TV" || got.UDN != "uuid:test-udn" {
+		t.Fatalf("unexpected device: %+v", got)
+	}
+}
+
+// TestSearchAllMergesAcrossJobsAndSkipsFailures drives searchAll directly
+// since it's where the multi-interface/address-family fan-out this request
+// added actually lives. Two jobs on the loopback interface answer with the
+// same location (simulating one device seen over two interfaces/address
+// families) and must be deduped into a single result; a third job against a
+// nonexistent interface must fail to join the group and be skipped rather
+// than failing discovery as a whole.
+func TestSearchAllMergesAcrossJobsAndSkipsFailures(t *testing.T) {
+	iface, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface available in this environment: %v", err)
+	}
+
+	const wantLocation = "http://127.0.0.1:9999/desc.xml"
+	msg := []byte("M-SEARCH test\r\n")
+
+	respondOn := func(port int) {
+		time.Sleep(50 * time.Millisecond)
+		conn, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nLocation: " + wantLocation + "\r\n\r\n"))
+	}
+
+	const portA = 19219
+	const portB = 19220
+	go respondOn(portA)
+	go respondOn(portB)
+
+	jobs := []searchJob{
+		{iface: *iface, groupAddr: &net.UDPAddr{IP: net.ParseIP(multicastAddressV4), Port: portA}, msg: msg},
+		{iface: *iface, groupAddr: &net.UDPAddr{IP: net.ParseIP(multicastAddressV4), Port: portB}, msg: msg},
+		{iface: net.Interface{Name: "does-not-exist-0"}, groupAddr: &net.UDPAddr{IP: net.ParseIP(multicastAddressV4), Port: 19221}, msg: msg},
+	}
+
+	locations := searchAll(jobs)
+	sort.Strings(locations)
+
+	if len(locations) != 1 || locations[0] != wantLocation {
+		t.Fatalf("expected a single deduped location %q, got %v", wantLocation, locations)
+	}
+}
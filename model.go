@@ -5,7 +5,9 @@ const (
 	wsRspErrorType      = "error"
 	wsRspRegisteredType = "registered"
 
-	RequestMsgType = "request"
+	RequestMsgType     = "request"
+	SubscribeMsgType   = "subscribe"
+	UnsubscribeMsgType = "unsubscribe"
 )
 
 type wsResponse struct {